@@ -0,0 +1,730 @@
+package markers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uug-ai/models/pkg/models"
+	"github.com/uug-ai/trace/pkg/opentelemetry"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ListFilter narrows List to one organisation's markers, optionally scoped
+// further to a device or group, with simple skip/limit paging.
+type ListFilter struct {
+	OrganisationId string
+	DeviceId       string
+	GroupId        string
+	Skip           int64
+	Limit          int64
+	// Sort is a field name on models.Marker's bson document, prefixed with
+	// "-" for descending. Defaults to "-startTimestamp".
+	Sort string
+}
+
+// SearchQuery narrows Search to a name/tag/event substring within a time
+// window, in addition to the same organisation/device/group scoping List
+// offers.
+type SearchQuery struct {
+	OrganisationId string
+	DeviceId       string
+	GroupId        string
+	// Query is matched as a case-insensitive substring against marker
+	// names, tag names and event names.
+	Query string
+	Start int64
+	End   int64
+	Skip  int64
+	Limit int64
+	// Sort is a field name on models.Marker's bson document, prefixed with
+	// "-" for descending. Defaults to "-startTimestamp".
+	Sort string
+}
+
+func sortDoc(sort string, fallback string) bson.M {
+	if sort == "" {
+		sort = fallback
+	}
+	if strings.HasPrefix(sort, "-") {
+		return bson.M{strings.TrimPrefix(sort, "-"): -1}
+	}
+	return bson.M{sort: 1}
+}
+
+// Get returns a single marker by its hex ObjectID.
+func Get(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, id string) (models.Marker, error) {
+
+	ctxGet, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxGet, TIMEOUT)
+	defer cancel()
+
+	markerObjectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Marker{}, fmt.Errorf("invalid id format: %w", err)
+	}
+
+	var marker models.Marker
+	c := client.Database(DatabaseName).Collection(MARKERS_COLLECTION)
+	if err := c.FindOne(ctx, bson.M{"_id": markerObjectId}).Decode(&marker); err != nil {
+		return models.Marker{}, fmt.Errorf("failed to get marker: %w", err)
+	}
+
+	return marker, nil
+}
+
+// List returns the markers matching filter, newest first unless filter.Sort
+// says otherwise.
+func List(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, filter ListFilter) ([]models.Marker, error) {
+
+	ctxList, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxList, TIMEOUT)
+	defer cancel()
+
+	query := bson.M{"organisationId": filter.OrganisationId}
+	if filter.DeviceId != "" {
+		query["deviceId"] = filter.DeviceId
+	}
+	if filter.GroupId != "" {
+		query["groupId"] = filter.GroupId
+	}
+
+	opts := options.Find().SetSort(sortDoc(filter.Sort, "-startTimestamp"))
+	if filter.Skip > 0 {
+		opts.SetSkip(filter.Skip)
+	}
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	c := client.Database(DatabaseName).Collection(MARKERS_COLLECTION)
+	cursor, err := c.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list markers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var markers []models.Marker
+	if err := cursor.All(ctx, &markers); err != nil {
+		return nil, fmt.Errorf("failed to decode markers: %w", err)
+	}
+
+	return markers, nil
+}
+
+// matchingMarkerIds resolves query's name/tag/event substring and [Start, End]
+// time window against the marker_option_ranges/marker_tag_option_ranges/
+// marker_event_option_ranges collections, returning the union of markerId
+// values any of them report. Matching the range collections rather than the
+// marker's own startTimestamp/endTimestamp lets an event whose sub-range
+// falls inside the window surface its marker even when the marker's overall
+// span does not.
+func matchingMarkerIds(ctx context.Context, db *mongo.Database, query SearchQuery) ([]primitive.ObjectID, error) {
+	rangeFilter := bson.M{"organisationId": query.OrganisationId}
+	if query.DeviceId != "" {
+		rangeFilter["deviceId"] = query.DeviceId
+	}
+	if query.GroupId != "" {
+		rangeFilter["groupId"] = query.GroupId
+	}
+	if query.End > 0 {
+		rangeFilter["start"] = bson.M{"$lte": query.End}
+	}
+	if query.Start > 0 {
+		rangeFilter["end"] = bson.M{"$gte": query.Start}
+	}
+	if query.Query != "" {
+		rangeFilter["value"] = primitive.Regex{Pattern: regexp.QuoteMeta(query.Query), Options: "i"}
+	}
+
+	seen := make(map[primitive.ObjectID]struct{})
+	var markerIds []primitive.ObjectID
+	for _, rangeCollection := range []string{
+		MARKER_OPTION_RANGES_COLLECTION,
+		MARKER_TAG_OPTION_RANGES_COLLECTION,
+		MARKER_EVENT_OPTION_RANGES_COLLECTION,
+	} {
+		values, err := db.Collection(rangeCollection).Distinct(ctx, "markerId", rangeFilter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match %s: %w", rangeCollection, err)
+		}
+		for _, value := range values {
+			markerId, ok := value.(primitive.ObjectID)
+			if !ok {
+				continue
+			}
+			if _, exists := seen[markerId]; !exists {
+				seen[markerId] = struct{}{}
+				markerIds = append(markerIds, markerId)
+			}
+		}
+	}
+
+	return markerIds, nil
+}
+
+// Search returns the markers matching query's organisation/device/group
+// scope, name/tag/event substring and [Start, End] time window. The
+// substring and time window are matched against the range collections
+// (marker_option_ranges and friends) rather than the markers collection
+// itself, since an event's own start/end can differ from its marker's.
+func Search(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, query SearchQuery) ([]models.Marker, error) {
+
+	ctxSearch, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxSearch, TIMEOUT)
+	defer cancel()
+
+	db := client.Database(DatabaseName)
+
+	filter := bson.M{"organisationId": query.OrganisationId}
+	if query.DeviceId != "" {
+		filter["deviceId"] = query.DeviceId
+	}
+	if query.GroupId != "" {
+		filter["groupId"] = query.GroupId
+	}
+
+	if query.Query != "" || query.Start > 0 || query.End > 0 {
+		markerIds, err := matchingMarkerIds(ctx, db, query)
+		if err != nil {
+			return nil, err
+		}
+		filter["_id"] = bson.M{"$in": markerIds}
+	}
+
+	opts := options.Find().SetSort(sortDoc(query.Sort, "-startTimestamp"))
+	if query.Skip > 0 {
+		opts.SetSkip(query.Skip)
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(query.Limit)
+	}
+
+	c := db.Collection(MARKERS_COLLECTION)
+	cursor, err := c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search markers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var markers []models.Marker
+	if err := cursor.All(ctx, &markers); err != nil {
+		return nil, fmt.Errorf("failed to decode markers: %w", err)
+	}
+
+	return markers, nil
+}
+
+// markerMediaIds returns the media object IDs AddMarkerToMongodb/
+// insertMarkerBatch recorded on marker.Id when it was denormalized onto
+// them, or nil if the marker predates that bookkeeping (or was inserted
+// without any mediaIds).
+func markerMediaIds(ctx context.Context, db *mongo.Database, markerId primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var doc struct {
+		MediaIds []primitive.ObjectID `bson:"mediaIds"`
+	}
+	err := db.Collection(MARKERS_COLLECTION).
+		FindOne(ctx, bson.M{"_id": markerId}, options.FindOne().SetProjection(bson.M{"mediaIds": 1})).
+		Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return doc.MediaIds, nil
+}
+
+// relatedMedia returns the media documents marker's names/tags/events were
+// denormalized onto when it was inserted, keyed by the same mediaIds
+// AddMarkerToMongodb/insertMarkerBatch wrote them into (not by
+// deviceId/groupId/timestamp, which need not match the media the caller
+// actually chose to denormalize onto).
+func relatedMedia(ctx context.Context, db *mongo.Database, marker models.Marker) ([]bson.M, error) {
+	mediaIds, err := markerMediaIds(ctx, db, marker.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(mediaIds) == 0 {
+		return nil, nil
+	}
+
+	c := db.Collection(MEDIA_COLLECTION)
+	cursor, err := c.Find(ctx, bson.M{"_id": bson.M{"$in": mediaIds}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var media []bson.M
+	if err := cursor.All(ctx, &media); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// stillReferenced reports whether any marker other than excludeId, also
+// denormalized onto mediaId, still resolves name/tag/event value within
+// the same organisation.
+func stillReferenced(ctx context.Context, db *mongo.Database, field string, value string, marker models.Marker, mediaId primitive.ObjectID, excludeId primitive.ObjectID) (bool, error) {
+	c := db.Collection(MARKERS_COLLECTION)
+	filter := bson.M{
+		"_id":            bson.M{"$ne": excludeId},
+		"organisationId": marker.OrganisationId,
+		"mediaIds":       mediaId,
+		field:            value,
+	}
+	count, err := c.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// pullStaleMediaReferences removes marker's names/tags/events from any media
+// document it was denormalized onto, but only for values no longer
+// referenced by any other marker tied to that media.
+func pullStaleMediaReferences(ctx context.Context, db *mongo.Database, marker models.Marker) error {
+	media, err := relatedMedia(ctx, db, marker)
+	if err != nil {
+		return fmt.Errorf("failed to find related media: %w", err)
+	}
+
+	mediaCol := db.Collection(MEDIA_COLLECTION)
+	for _, doc := range media {
+		mediaId, _ := doc["_id"].(primitive.ObjectID)
+
+		pullDoc := bson.M{}
+
+		if marker.Name != "" {
+			referenced, err := stillReferenced(ctx, db, "name", marker.Name, marker, mediaId, marker.Id)
+			if err != nil {
+				return err
+			}
+			if !referenced {
+				pullDoc["markerNames"] = marker.Name
+			}
+		}
+		var staleTags []string
+		for _, tag := range marker.Tags {
+			if tag.Name == "" {
+				continue
+			}
+			referenced, err := stillReferenced(ctx, db, "tags.name", tag.Name, marker, mediaId, marker.Id)
+			if err != nil {
+				return err
+			}
+			if !referenced {
+				staleTags = append(staleTags, tag.Name)
+			}
+		}
+		if len(staleTags) > 0 {
+			pullDoc["tagNames"] = bson.M{"$in": staleTags}
+		}
+		var staleEvents []string
+		for _, event := range marker.Events {
+			if event.Name == "" {
+				continue
+			}
+			referenced, err := stillReferenced(ctx, db, "events.name", event.Name, marker, mediaId, marker.Id)
+			if err != nil {
+				return err
+			}
+			if !referenced {
+				staleEvents = append(staleEvents, event.Name)
+			}
+		}
+		if len(staleEvents) > 0 {
+			pullDoc["eventNames"] = bson.M{"$in": staleEvents}
+		}
+		if marker.Type == MarkerTypeFace && marker.Face.SubjectUID != "" {
+			referenced, err := stillReferenced(ctx, db, "face.subjectUID", marker.Face.SubjectUID, marker, mediaId, marker.Id)
+			if err != nil {
+				return err
+			}
+			if !referenced {
+				pullDoc["subjectUIDs"] = marker.Face.SubjectUID
+			}
+		}
+
+		if len(pullDoc) == 0 {
+			continue
+		}
+		if _, err := mediaCol.UpdateOne(ctx, bson.M{"_id": mediaId}, bson.M{"$pull": pullDoc}); err != nil {
+			return fmt.Errorf("failed to pull stale media references: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a marker and fans the removal out to every collection it
+// was denormalized into: the three *_option_ranges collections, and the
+// owning media document's markerNames/tagNames/eventNames/subjectUIDs
+// arrays (pulling a name only once no other marker in that media still
+// references it).
+func Delete(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, id string) error {
+
+	ctxDelete, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxDelete, TIMEOUT)
+	defer cancel()
+
+	markerObjectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("invalid id format: %w", err)
+	}
+
+	db := client.Database(DatabaseName)
+	c := db.Collection(MARKERS_COLLECTION)
+
+	var marker models.Marker
+	if err := c.FindOne(ctx, bson.M{"_id": markerObjectId}).Decode(&marker); err != nil {
+		return fmt.Errorf("failed to find marker: %w", err)
+	}
+
+	// Pull from media while the marker's mediaIds can still be read;
+	// stillReferenced already excludes the marker itself.
+	if err := pullStaleMediaReferences(ctx, db, marker); err != nil {
+		return err
+	}
+
+	if _, err := c.DeleteOne(ctx, bson.M{"_id": markerObjectId}); err != nil {
+		return fmt.Errorf("failed to delete marker: %w", err)
+	}
+
+	for _, rangeCollection := range []string{
+		MARKER_OPTION_RANGES_COLLECTION,
+		MARKER_TAG_OPTION_RANGES_COLLECTION,
+		MARKER_EVENT_OPTION_RANGES_COLLECTION,
+	} {
+		if _, err := db.Collection(rangeCollection).DeleteMany(ctx, bson.M{"markerId": markerObjectId}); err != nil {
+			return fmt.Errorf("failed to delete %s ranges: %w", rangeCollection, err)
+		}
+	}
+
+	return nil
+}
+
+// Update applies patch's non-empty Name, Tags, Events and Categories and
+// non-zero StartTimestamp/EndTimestamp onto the marker identified by id
+// (other fields of patch are ignored), then refreshes the denormalized collections to match: the old range
+// docs are replaced with fresh ones for the new values, and the owning media
+// document's arrays are pruned of values no other marker still references
+// and extended with any newly added ones.
+func Update(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, id string, patch models.Marker) (models.Marker, error) {
+
+	ctxUpdate, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxUpdate, TIMEOUT)
+	defer cancel()
+
+	markerObjectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Marker{}, fmt.Errorf("invalid id format: %w", err)
+	}
+
+	db := client.Database(DatabaseName)
+	c := db.Collection(MARKERS_COLLECTION)
+
+	var marker models.Marker
+	if err := c.FindOne(ctx, bson.M{"_id": markerObjectId}).Decode(&marker); err != nil {
+		return models.Marker{}, fmt.Errorf("failed to find marker: %w", err)
+	}
+
+	// Fan out the removal of the marker's current values before they are
+	// overwritten, so stale names don't linger on the media document.
+	if err := pullStaleMediaReferences(ctx, db, marker); err != nil {
+		return models.Marker{}, err
+	}
+	for _, rangeCollection := range []string{
+		MARKER_OPTION_RANGES_COLLECTION,
+		MARKER_TAG_OPTION_RANGES_COLLECTION,
+		MARKER_EVENT_OPTION_RANGES_COLLECTION,
+	} {
+		if _, err := db.Collection(rangeCollection).DeleteMany(ctx, bson.M{"markerId": markerObjectId}); err != nil {
+			return models.Marker{}, fmt.Errorf("failed to delete %s ranges: %w", rangeCollection, err)
+		}
+	}
+
+	if patch.Name != "" {
+		marker.Name = patch.Name
+	}
+	if patch.Tags != nil {
+		marker.Tags = patch.Tags
+	}
+	if patch.Events != nil {
+		marker.Events = patch.Events
+	}
+	if patch.Categories != nil {
+		marker.Categories = patch.Categories
+	}
+	if patch.StartTimestamp != 0 {
+		marker.StartTimestamp = patch.StartTimestamp
+	}
+	if patch.EndTimestamp != 0 {
+		marker.EndTimestamp = patch.EndTimestamp
+	}
+	marker.Duration = marker.EndTimestamp - marker.StartTimestamp
+
+	// ReplaceOne writes back only models.Marker's fields, so carry the
+	// recorded mediaIds over to the replaced document.
+	mediaIds, err := markerMediaIds(ctx, db, markerObjectId)
+	if err != nil {
+		return models.Marker{}, fmt.Errorf("failed to find marker's media: %w", err)
+	}
+	if _, err := c.ReplaceOne(ctx, bson.M{"_id": markerObjectId}, marker); err != nil {
+		return models.Marker{}, fmt.Errorf("failed to update marker: %w", err)
+	}
+	if len(mediaIds) > 0 {
+		if _, err := c.UpdateOne(ctx, bson.M{"_id": markerObjectId}, bson.M{"$set": bson.M{"mediaIds": mediaIds}}); err != nil {
+			return models.Marker{}, fmt.Errorf("failed to keep marker's media: %w", err)
+		}
+	}
+
+	// Re-run the same option/range fanout AddMarkerToMongodb does for a
+	// freshly inserted marker, now with the patched values.
+	if err := applyMarkerFanout(ctx, db, marker); err != nil {
+		return models.Marker{}, err
+	}
+
+	media, err := relatedMedia(ctx, db, marker)
+	if err != nil {
+		return models.Marker{}, fmt.Errorf("failed to find related media: %w", err)
+	}
+	mediaCol := db.Collection(MEDIA_COLLECTION)
+	addDoc := bson.M{}
+	if marker.Name != "" {
+		addDoc["markerNames"] = marker.Name
+	}
+	if len(marker.Tags) > 0 {
+		var tagNames []string
+		for _, tag := range marker.Tags {
+			if tag.Name != "" {
+				tagNames = append(tagNames, tag.Name)
+			}
+		}
+		if len(tagNames) > 0 {
+			addDoc["tagNames"] = bson.M{"$each": tagNames}
+		}
+	}
+	if len(marker.Events) > 0 {
+		var eventNames []string
+		for _, event := range marker.Events {
+			if event.Name != "" {
+				eventNames = append(eventNames, event.Name)
+			}
+		}
+		if len(eventNames) > 0 {
+			addDoc["eventNames"] = bson.M{"$each": eventNames}
+		}
+	}
+	if marker.Type == MarkerTypeFace && marker.Face.SubjectUID != "" {
+		addDoc["subjectUIDs"] = marker.Face.SubjectUID
+	}
+	if len(addDoc) > 0 {
+		for _, doc := range media {
+			mediaId, _ := doc["_id"].(primitive.ObjectID)
+			if _, err := mediaCol.UpdateOne(ctx, bson.M{"_id": mediaId}, bson.M{"$addToSet": addDoc}); err != nil {
+				return models.Marker{}, fmt.Errorf("failed to refresh media with marker data: %w", err)
+			}
+		}
+	}
+
+	return marker, nil
+}
+
+// Recount rebuilds marker_options, marker_tag_options, marker_event_options
+// and marker_category_options for an organisation by aggregating its
+// markers collection from scratch. Operators use this to recover from drift
+// caused by AddMarkerToMongodb's non-atomic writes.
+func Recount(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, orgId string) error {
+
+	ctxRecount, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxRecount, TIMEOUT)
+	defer cancel()
+
+	if orgId == "" {
+		return errors.New("organisationId is required")
+	}
+
+	db := client.Database(DatabaseName)
+
+	for _, optionsCollection := range []string{
+		MARKER_OPTIONS_COLLECTION,
+		MARKER_TAG_OPTIONS_COLLECTION,
+		MARKER_EVENT_OPTIONS_COLLECTION,
+		MARKER_CATEGORY_OPTIONS_COLLECTION,
+	} {
+		if _, err := db.Collection(optionsCollection).DeleteMany(ctx, bson.M{"organisationId": orgId}); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", optionsCollection, err)
+		}
+	}
+
+	cursor, err := db.Collection(MARKERS_COLLECTION).Find(ctx, bson.M{"organisationId": orgId})
+	if err != nil {
+		return fmt.Errorf("failed to scan markers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var markers []models.Marker
+	if err := cursor.All(ctx, &markers); err != nil {
+		return fmt.Errorf("failed to decode markers: %w", err)
+	}
+
+	// nameOrder preserves first-seen order; nameCategories accumulates the
+	// categories of every marker sharing a name, not just the first one, so
+	// the rebuilt marker_options.categories matches what the live
+	// AddMarkerToMongodb/Update $addToSet path would have accumulated.
+	var nameOrder []string
+	nameCategories := make(map[string]map[string]struct{})
+	tagSet := make(map[string]struct{})
+	eventSet := make(map[string]struct{})
+	categorySet := make(map[string]struct{})
+
+	var markerOptUpserts []mongo.WriteModel
+	var tagOptUpserts []mongo.WriteModel
+	var eventOptUpserts []mongo.WriteModel
+	var categoryOptUpserts []mongo.WriteModel
+
+	now := time.Now().Unix()
+
+	for _, marker := range markers {
+		if marker.Name != "" {
+			categories, exists := nameCategories[marker.Name]
+			if !exists {
+				categories = make(map[string]struct{})
+				nameCategories[marker.Name] = categories
+				nameOrder = append(nameOrder, marker.Name)
+			}
+			for _, cat := range marker.Categories {
+				if cat.Name != "" {
+					categories[cat.Name] = struct{}{}
+				}
+			}
+		}
+		for _, tag := range marker.Tags {
+			if tag.Name == "" {
+				continue
+			}
+			if _, exists := tagSet[tag.Name]; !exists {
+				tagSet[tag.Name] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": tag.Name, "organisationId": orgId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          tag.Name,
+						"text":           tag.Name,
+						"organisationId": orgId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{"updatedAt": now},
+				})
+				up.SetUpsert(true)
+				tagOptUpserts = append(tagOptUpserts, up)
+			}
+		}
+		for _, event := range marker.Events {
+			if event.Name == "" {
+				continue
+			}
+			if _, exists := eventSet[event.Name]; !exists {
+				eventSet[event.Name] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": event.Name, "organisationId": orgId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          event.Name,
+						"text":           event.Name,
+						"organisationId": orgId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{"updatedAt": now},
+				})
+				up.SetUpsert(true)
+				eventOptUpserts = append(eventOptUpserts, up)
+			}
+		}
+		for _, category := range marker.Categories {
+			if category.Name == "" {
+				continue
+			}
+			if _, exists := categorySet[category.Name]; !exists {
+				categorySet[category.Name] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": category.Name, "organisationId": orgId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          category.Name,
+						"text":           category.Name,
+						"organisationId": orgId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{"updatedAt": now},
+				})
+				up.SetUpsert(true)
+				categoryOptUpserts = append(categoryOptUpserts, up)
+			}
+		}
+	}
+
+	for _, name := range nameOrder {
+		var categoryNamesList []string
+		for categoryName := range nameCategories[name] {
+			categoryNamesList = append(categoryNamesList, categoryName)
+		}
+		up := mongo.NewUpdateOneModel()
+		up.SetFilter(bson.M{"value": name, "organisationId": orgId})
+		up.SetUpdate(bson.M{
+			"$setOnInsert": bson.M{
+				"value":          name,
+				"text":           name,
+				"organisationId": orgId,
+				"createdAt":      now,
+			},
+			"$set": bson.M{"updatedAt": now},
+			"$addToSet": bson.M{
+				"categories": bson.M{"$each": categoryNamesList},
+			},
+		})
+		up.SetUpsert(true)
+		markerOptUpserts = append(markerOptUpserts, up)
+	}
+
+	if len(markerOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_OPTIONS_COLLECTION).BulkWrite(ctx, markerOptUpserts); err != nil {
+			return fmt.Errorf("failed to rebuild marker options: %w", err)
+		}
+	}
+	if len(tagOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_TAG_OPTIONS_COLLECTION).BulkWrite(ctx, tagOptUpserts); err != nil {
+			return fmt.Errorf("failed to rebuild tag options: %w", err)
+		}
+	}
+	if len(eventOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_EVENT_OPTIONS_COLLECTION).BulkWrite(ctx, eventOptUpserts); err != nil {
+			return fmt.Errorf("failed to rebuild event options: %w", err)
+		}
+	}
+	if len(categoryOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_CATEGORY_OPTIONS_COLLECTION).BulkWrite(ctx, categoryOptUpserts); err != nil {
+			return fmt.Errorf("failed to rebuild category options: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package markers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/uug-ai/models/pkg/models"
+	"github.com/uug-ai/trace/pkg/opentelemetry"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var MARKER_FILES_COLLECTION = "marker_files"
+
+// ObjectStore is the minimal surface AppendMarkerFileContent needs to stream
+// bytes into wherever attachments actually live (local disk, S3, ...).
+// Storage is the package-level default; swap it out in tests or to point at
+// a different backend.
+type ObjectStore interface {
+	// Append writes chunk to the end of the object at storageURI, creating
+	// it if it does not exist yet.
+	Append(ctx context.Context, storageURI string, chunk []byte) error
+	// Read returns the full current content of the object at storageURI.
+	Read(ctx context.Context, storageURI string) ([]byte, error)
+}
+
+var Storage ObjectStore = NewLocalObjectStore("marker-files")
+
+// LocalObjectStore is a filesystem-backed ObjectStore rooted at BaseDir.
+// It is the default Storage implementation for single-node deployments.
+type LocalObjectStore struct {
+	BaseDir string
+
+	mu sync.Mutex
+}
+
+func NewLocalObjectStore(baseDir string) *LocalObjectStore {
+	return &LocalObjectStore{BaseDir: baseDir}
+}
+
+func (s *LocalObjectStore) Append(ctx context.Context, storageURI string, chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.BaseDir, storageURI)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open storage object: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(chunk); err != nil {
+		return fmt.Errorf("failed to append to storage object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalObjectStore) Read(ctx context.Context, storageURI string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.BaseDir, storageURI)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage object: %w", err)
+	}
+
+	return content, nil
+}
+
+// CreateMarkerFile creates an empty placeholder attachment for markerId.
+// The returned MarkerFile has Size 0 and no SHA256 yet; call
+// AppendMarkerFileContent to stream its content in, then AttachFilesToMarker
+// to link it onto the marker document.
+func CreateMarkerFile(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, markerId string, name string) (models.MarkerFile, error) {
+
+	ctxCreateMarkerFile, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxCreateMarkerFile, TIMEOUT)
+	defer cancel()
+
+	markerObjectId, err := primitive.ObjectIDFromHex(markerId)
+	if err != nil {
+		return models.MarkerFile{}, fmt.Errorf("invalid markerId format: %w", err)
+	}
+
+	safeName := filepath.Base(name)
+	if safeName == "." || safeName == ".." || safeName == string(filepath.Separator) {
+		return models.MarkerFile{}, fmt.Errorf("invalid file name: %q", name)
+	}
+
+	now := time.Now().Unix()
+	file := models.MarkerFile{
+		Id:         primitive.NewObjectID(),
+		Name:       name,
+		MIMEType:   mime.TypeByExtension(filepath.Ext(name)),
+		Size:       0,
+		SHA256:     "",
+		StorageURI: fmt.Sprintf("%s/%s-%s", markerId, primitive.NewObjectID().Hex(), safeName),
+		CreatedAt:  now,
+	}
+
+	c := client.Database(DatabaseName).Collection(MARKER_FILES_COLLECTION)
+	_, err = c.InsertOne(ctx, bson.M{
+		"_id":        file.Id,
+		"markerId":   markerObjectId,
+		"name":       file.Name,
+		"mimeType":   file.MIMEType,
+		"size":       file.Size,
+		"sha256":     file.SHA256,
+		"storageUri": file.StorageURI,
+		"createdAt":  file.CreatedAt,
+	})
+	if err != nil {
+		return models.MarkerFile{}, fmt.Errorf("failed to create marker file: %w", err)
+	}
+
+	return file, nil
+}
+
+// AppendMarkerFileContent streams chunk into the object storage location
+// backing fileId and refreshes its Size/SHA256 in the marker_files
+// collection. It is safe to call repeatedly to stream large attachments
+// (crops, exported clips, JSON reports) in parts: size is incremented and
+// the SHA256 is carried forward from a persisted running hash state, so
+// each call only touches the new chunk rather than the whole object.
+func AppendMarkerFileContent(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, fileId string, chunk []byte) error {
+
+	ctxAppendMarkerFileContent, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxAppendMarkerFileContent, TIMEOUT)
+	defer cancel()
+
+	fileObjectId, err := primitive.ObjectIDFromHex(fileId)
+	if err != nil {
+		return fmt.Errorf("invalid fileId format: %w", err)
+	}
+
+	c := client.Database(DatabaseName).Collection(MARKER_FILES_COLLECTION)
+
+	var doc struct {
+		StorageURI string `bson:"storageUri"`
+		HashState  []byte `bson:"hashState"`
+	}
+	if err := c.FindOne(ctx, bson.M{"_id": fileObjectId}).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to find marker file: %w", err)
+	}
+
+	h := sha256.New()
+	if len(doc.HashState) > 0 {
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(doc.HashState); err != nil {
+			return fmt.Errorf("failed to restore marker file hash state: %w", err)
+		}
+	}
+
+	if err := Storage.Append(ctx, doc.StorageURI, chunk); err != nil {
+		return err
+	}
+
+	h.Write(chunk)
+	sum := h.Sum(nil)
+	hashState, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to persist marker file hash state: %w", err)
+	}
+
+	_, err = c.UpdateOne(ctx, bson.M{"_id": fileObjectId}, bson.M{
+		"$inc": bson.M{"size": len(chunk)},
+		"$set": bson.M{
+			"sha256":    hex.EncodeToString(sum),
+			"hashState": hashState,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update marker file metadata: %w", err)
+	}
+
+	return nil
+}
+
+// AttachFilesToMarker resolves fileIds against the marker_files collection
+// and pushes them onto the marker document's Files array, so callers running
+// ML/analysis over a marker's timeline range can attach their output (crops,
+// exported clips, JSON reports) back onto the marker.
+func AttachFilesToMarker(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, markerId string, fileIds ...string) error {
+
+	ctxAttachFilesToMarker, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxAttachFilesToMarker, TIMEOUT)
+	defer cancel()
+
+	markerObjectId, err := primitive.ObjectIDFromHex(markerId)
+	if err != nil {
+		return fmt.Errorf("invalid markerId format: %w", err)
+	}
+
+	fileObjectIds := make([]primitive.ObjectID, 0, len(fileIds))
+	for _, fileId := range fileIds {
+		fileObjectId, err := primitive.ObjectIDFromHex(fileId)
+		if err != nil {
+			return fmt.Errorf("invalid fileId format: %w", err)
+		}
+		fileObjectIds = append(fileObjectIds, fileObjectId)
+	}
+
+	db := client.Database(DatabaseName)
+	filesCol := db.Collection(MARKER_FILES_COLLECTION)
+
+	cursor, err := filesCol.Find(ctx, bson.M{"_id": bson.M{"$in": fileObjectIds}})
+	if err != nil {
+		return fmt.Errorf("failed to resolve marker files: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var files []models.MarkerFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return fmt.Errorf("failed to decode marker files: %w", err)
+	}
+
+	if len(files) != len(fileObjectIds) {
+		return fmt.Errorf("one or more fileIds could not be resolved")
+	}
+
+	markersCol := db.Collection(MARKERS_COLLECTION)
+	_, err = markersCol.UpdateOne(ctx, bson.M{"_id": markerObjectId}, bson.M{
+		"$push": bson.M{
+			"files": bson.M{"$each": files},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach files to marker: %w", err)
+	}
+
+	return nil
+}
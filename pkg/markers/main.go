@@ -35,3 +35,28 @@ func (m *Marker) Create(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 
 	return insertedMarker, nil
 }
+
+// CreateBatch inserts many markers in a single transactional write (not
+// transactional when AllowTransactions is false). It is
+// the counterpart to Create for callers ingesting a batch at once, such as
+// an analytics pipeline producing hundreds of markers per video.
+func (m *Marker) CreateBatch(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, markers []models.Marker, mediaIds ...string) ([]models.Marker, error) {
+
+	for i := range markers {
+		// We require a marker name to be set, as this is used to identify the marker.
+		if markers[i].Name == "" {
+			return nil, errors.New("marker name is required")
+		}
+
+		// Set the duration, difference between start and end time
+		markers[i].Duration = markers[i].EndTimestamp - markers[i].StartTimestamp
+	}
+
+	// Add the markers to the database
+	insertedMarkers, err := AddMarkersToMongodb(ctxTracer, tracer, client, markers, mediaIds...)
+	if err != nil {
+		return nil, err
+	}
+
+	return insertedMarkers, nil
+}
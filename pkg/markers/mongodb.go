@@ -12,6 +12,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
@@ -23,12 +24,25 @@ var (
 	MARKER_EVENT_OPTIONS_COLLECTION       = "marker_event_options"
 	MARKER_EVENT_OPTION_RANGES_COLLECTION = "marker_event_option_ranges"
 	MARKER_CATEGORY_OPTIONS_COLLECTION    = "marker_category_options"
+	MARKER_SUBJECTS_COLLECTION            = "marker_subjects"
 	MEDIA_COLLECTION                      = "media"
 
 	DatabaseName = "Kerberos"
 	TIMEOUT      = 10 * time.Second
 )
 
+// Marker type discriminators. A marker with an empty Type is treated as the
+// original, generic time-range marker.
+const (
+	MarkerTypeFace = "face"
+)
+
+// AllowTransactions controls whether AddMarkersToMongodb wraps its batch
+// write in a MongoDB session/transaction. Transactions require a replica
+// set or sharded cluster; deployments running a standalone mongod should
+// set this to false to fall back to the non-transactional path.
+var AllowTransactions = true
+
 func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, marker models.Marker, mediaIds ...string) (models.Marker, error) {
 
 	ctxAddMarkerToMongodb, span := tracer.CreateSpan(ctxTracer, map[string]string{})
@@ -62,6 +76,96 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 
 	// As part of the marker we also need to insert into some other collections for performance reasons.
 	// For example on the media page we have marker options, marker event options, marker tag options, marker category options.
+	if err := applyMarkerFanout(ctx, db, marker); err != nil {
+		return marker, err
+	}
+
+	// If mediaIds are provided, update the media documents with marker names, tag names, and event names
+	var denormalizedMediaIds []primitive.ObjectID
+	for _, mediaId := range mediaIds {
+		if mediaId == "" {
+			continue
+		}
+
+		mediaObjectId, err := primitive.ObjectIDFromHex(mediaId)
+		if err != nil {
+			return marker, fmt.Errorf("invalid mediaId format: %w", err)
+		}
+
+		// Collect unique marker names, tag names, and event names
+		var markerNames []string
+		if marker.Name != "" {
+			markerNames = append(markerNames, marker.Name)
+		}
+
+		var tagNames []string
+		for _, tag := range marker.Tags {
+			if tag.Name != "" {
+				tagNames = append(tagNames, tag.Name)
+			}
+		}
+
+		var eventNames []string
+		for _, event := range marker.Events {
+			if event.Name != "" {
+				eventNames = append(eventNames, event.Name)
+			}
+		}
+
+		// Build update document using $addToSet with $each to ensure uniqueness
+		updateDoc := bson.M{}
+		if len(markerNames) > 0 {
+			updateDoc["markerNames"] = bson.M{"$each": markerNames}
+		}
+		if len(tagNames) > 0 {
+			updateDoc["tagNames"] = bson.M{"$each": tagNames}
+		}
+		if len(eventNames) > 0 {
+			updateDoc["eventNames"] = bson.M{"$each": eventNames}
+		}
+		if marker.Type == MarkerTypeFace && marker.Face.SubjectUID != "" {
+			updateDoc["subjectUIDs"] = bson.M{"$each": []string{marker.Face.SubjectUID}}
+		}
+
+		if len(updateDoc) > 0 {
+			mediaCol := db.Collection(MEDIA_COLLECTION)
+			filter := bson.M{
+				"_id":            mediaObjectId,
+				"startTimestamp": bson.M{"$lte": marker.StartTimestamp},
+				"endTimestamp":   bson.M{"$gte": marker.StartTimestamp},
+			}
+			update := bson.M{"$addToSet": updateDoc}
+			res, err := mediaCol.UpdateOne(ctx, filter, update)
+			if err != nil {
+				return marker, fmt.Errorf("failed to update media with marker data: %w", err)
+			}
+			if res.MatchedCount > 0 {
+				denormalizedMediaIds = append(denormalizedMediaIds, mediaObjectId)
+			}
+		}
+	}
+
+	// Record exactly which media documents the marker was denormalized onto,
+	// so Delete/Update can find and clean up the same documents later instead
+	// of guessing by deviceId/groupId/timestamp overlap.
+	if len(denormalizedMediaIds) > 0 {
+		if _, err := c.UpdateOne(ctx, bson.M{"_id": marker.Id}, bson.M{
+			"$set": bson.M{"mediaIds": denormalizedMediaIds},
+		}); err != nil {
+			return marker, fmt.Errorf("failed to record marker's denormalized media: %w", err)
+		}
+	}
+
+	return marker, nil
+}
+
+// applyMarkerFanout upserts the option/range/subject collections a marker is
+// denormalized into: marker_options, marker_tag_options,
+// marker_event_options, marker_category_options and their *_ranges
+// counterparts, plus marker_subjects for face markers. It is shared by
+// AddMarkerToMongodb and Update so editing a marker refreshes the same
+// collections a fresh insert does.
+func applyMarkerFanout(ctx context.Context, db *mongo.Database, marker models.Marker) error {
 
 	// Collections for tracking unique entries
 	nameSet := make(map[string]struct{})
@@ -112,6 +216,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 			markerOptUpserts = append(markerOptUpserts, up)
 		}
 		markerRangeDocs = append(markerRangeDocs, bson.M{
+			"markerId":       marker.Id,
 			"value":          marker.Name,
 			"text":           marker.Name,
 			"organisationId": marker.OrganisationId,
@@ -147,6 +252,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 			tagOptUpserts = append(tagOptUpserts, up)
 		}
 		tagRangeDocs = append(tagRangeDocs, bson.M{
+			"markerId":       marker.Id,
 			"value":          tag.Name,
 			"text":           tag.Name,
 			"organisationId": marker.OrganisationId,
@@ -182,6 +288,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 			eventOptUpserts = append(eventOptUpserts, up)
 		}
 		eventRangeDocs = append(eventRangeDocs, bson.M{
+			"markerId":       marker.Id,
 			"value":          event.Name,
 			"text":           event.Name,
 			"organisationId": marker.OrganisationId,
@@ -219,11 +326,37 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 		}
 	}
 
+	// face/subject linkage
+	if marker.Type == MarkerTypeFace && marker.Face.SubjectUID != "" {
+		subjectCol := db.Collection(MARKER_SUBJECTS_COLLECTION)
+		up := bson.M{
+			"$setOnInsert": bson.M{
+				"subjectUID":     marker.Face.SubjectUID,
+				"organisationId": marker.OrganisationId,
+				"createdAt":      now,
+			},
+			"$set": bson.M{
+				"subjectSrc": marker.Face.SubjectSrc,
+				"updatedAt":  now,
+			},
+			"$addToSet": bson.M{
+				"faceIds": marker.Face.FaceID,
+			},
+		}
+		opts := options.Update().SetUpsert(true)
+		if _, err := subjectCol.UpdateOne(ctx, bson.M{
+			"subjectUID":     marker.Face.SubjectUID,
+			"organisationId": marker.OrganisationId,
+		}, up, opts); err != nil {
+			return fmt.Errorf("failed to upsert marker subject: %w", err)
+		}
+	}
+
 	// Execute bulk operations for marker options
 	if len(markerOptUpserts) > 0 {
 		markerOptCol := db.Collection(MARKER_OPTIONS_COLLECTION)
 		if _, err := markerOptCol.BulkWrite(ctx, markerOptUpserts); err != nil {
-			return marker, fmt.Errorf("failed to upsert marker options: %w", err)
+			return fmt.Errorf("failed to upsert marker options: %w", err)
 		}
 	}
 
@@ -231,7 +364,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 	if len(markerRangeDocs) > 0 {
 		markerRangeCol := db.Collection(MARKER_OPTION_RANGES_COLLECTION)
 		if _, err := markerRangeCol.InsertMany(ctx, markerRangeDocs); err != nil {
-			return marker, fmt.Errorf("failed to insert marker ranges: %w", err)
+			return fmt.Errorf("failed to insert marker ranges: %w", err)
 		}
 	}
 
@@ -239,7 +372,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 	if len(tagOptUpserts) > 0 {
 		tagOptCol := db.Collection(MARKER_TAG_OPTIONS_COLLECTION)
 		if _, err := tagOptCol.BulkWrite(ctx, tagOptUpserts); err != nil {
-			return marker, fmt.Errorf("failed to upsert tag options: %w", err)
+			return fmt.Errorf("failed to upsert tag options: %w", err)
 		}
 	}
 
@@ -247,7 +380,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 	if len(tagRangeDocs) > 0 {
 		tagRangeCol := db.Collection(MARKER_TAG_OPTION_RANGES_COLLECTION)
 		if _, err := tagRangeCol.InsertMany(ctx, tagRangeDocs); err != nil {
-			return marker, fmt.Errorf("failed to insert tag ranges: %w", err)
+			return fmt.Errorf("failed to insert tag ranges: %w", err)
 		}
 	}
 
@@ -255,7 +388,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 	if len(eventOptUpserts) > 0 {
 		eventOptCol := db.Collection(MARKER_EVENT_OPTIONS_COLLECTION)
 		if _, err := eventOptCol.BulkWrite(ctx, eventOptUpserts); err != nil {
-			return marker, fmt.Errorf("failed to upsert event options: %w", err)
+			return fmt.Errorf("failed to upsert event options: %w", err)
 		}
 	}
 
@@ -263,7 +396,7 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 	if len(eventRangeDocs) > 0 {
 		eventRangeCol := db.Collection(MARKER_EVENT_OPTION_RANGES_COLLECTION)
 		if _, err := eventRangeCol.InsertMany(ctx, eventRangeDocs); err != nil {
-			return marker, fmt.Errorf("failed to insert event ranges: %w", err)
+			return fmt.Errorf("failed to insert event ranges: %w", err)
 		}
 	}
 
@@ -271,67 +404,417 @@ func AddMarkerToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer,
 	if len(categoryOptUpserts) > 0 {
 		categoryOptCol := db.Collection(MARKER_CATEGORY_OPTIONS_COLLECTION)
 		if _, err := categoryOptCol.BulkWrite(ctx, categoryOptUpserts); err != nil {
-			return marker, fmt.Errorf("failed to upsert category options: %w", err)
+			return fmt.Errorf("failed to upsert category options: %w", err)
 		}
 	}
 
-	// If mediaIds are provided, update the media documents with marker names, tag names, and event names
-	for _, mediaId := range mediaIds {
-		if mediaId == "" {
-			continue
-		}
+	return nil
+}
 
-		mediaObjectId, err := primitive.ObjectIDFromHex(mediaId)
+// FindMarkersBySubject returns every face marker linked to the given subject
+// within an organisation, newest first. This backs the "show me every
+// appearance of this person" view on the media timeline, and is also how an
+// auto-clustering pass would look up the markers it is about to re-label
+// once it promotes an unknown face into a named subject.
+func FindMarkersBySubject(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, orgId string, subjectUID string) ([]models.Marker, error) {
+
+	ctxFindMarkersBySubject, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxFindMarkersBySubject, TIMEOUT)
+	defer cancel()
+
+	c := client.Database(DatabaseName).Collection(MARKERS_COLLECTION)
+
+	filter := bson.M{
+		"organisationId":  orgId,
+		"type":            MarkerTypeFace,
+		"face.subjectUID": subjectUID,
+	}
+	opts := options.Find().SetSort(bson.M{"startTimestamp": -1})
+
+	cursor, err := c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find markers by subject: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var markers []models.Marker
+	if err := cursor.All(ctx, &markers); err != nil {
+		return nil, fmt.Errorf("failed to decode markers by subject: %w", err)
+	}
+
+	return markers, nil
+}
+
+// AddMarkersToMongodb inserts a batch of markers in a single pass. Unlike
+// AddMarkerToMongodb, which does one insert plus up to four bulk upserts plus
+// up to three InsertMany calls plus N media updates *per marker*, this
+// coalesces the option/tag/event/category/subject upserts and the range
+// inserts across the whole batch into a single BulkWrite/InsertMany call per
+// collection, and wraps everything in a MongoDB transaction so a batch is
+// either fully visible across every collection or not at all. This is the
+// path analytics pipelines ingesting hundreds of markers per video should
+// use instead of calling AddMarkerToMongodb in a loop.
+//
+// Transactions require a replica set or sharded cluster; set
+// AllowTransactions to false to fall back to the non-transactional path on a
+// standalone mongod.
+func AddMarkersToMongodb(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, markers []models.Marker, mediaIds ...string) ([]models.Marker, error) {
+
+	ctxAddMarkersToMongodb, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctxAddMarkersToMongodb, TIMEOUT)
+	defer cancel()
+
+	if len(markers) == 0 {
+		return nil, errors.New("no markers to insert")
+	}
+
+	var inserted []models.Marker
+	runBatch := func(batchCtx context.Context) error {
+		result, err := insertMarkerBatch(batchCtx, client, markers, mediaIds...)
 		if err != nil {
-			return marker, fmt.Errorf("invalid mediaId format: %w", err)
+			return err
 		}
+		inserted = result
+		return nil
+	}
 
-		// Collect unique marker names, tag names, and event names
-		var markerNames []string
+	if !AllowTransactions {
+		if err := runBatch(ctx); err != nil {
+			return nil, err
+		}
+		return inserted, nil
+	}
+
+	err := client.UseSessionWithOptions(ctx, options.Session(), func(sessCtx mongo.SessionContext) error {
+		_, err := sessCtx.WithTransaction(sessCtx, func(txCtx mongo.SessionContext) (interface{}, error) {
+			return nil, runBatch(txCtx)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert marker batch: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// insertMarkerBatch performs the actual coalesced writes for
+// AddMarkersToMongodb. It is shared by the transactional and
+// non-transactional paths, taking whatever context (plain or session) the
+// caller has already set up.
+func insertMarkerBatch(ctx context.Context, client *mongo.Client, markers []models.Marker, mediaIds ...string) ([]models.Marker, error) {
+
+	db := client.Database(DatabaseName)
+
+	// Assign IDs up front and insert every marker document in one call.
+	docs := make([]interface{}, len(markers))
+	for i := range markers {
+		markers[i].Id = primitive.NewObjectID()
+		docs[i] = markers[i]
+	}
+
+	c := db.Collection(MARKERS_COLLECTION)
+	if _, err := c.InsertMany(ctx, docs); err != nil {
+		return nil, fmt.Errorf("failed to insert markers: %w", err)
+	}
+
+	// Collections for tracking unique entries across the whole batch, keyed
+	// by value+organisationId so markers for different tenants never collide.
+	nameSet := make(map[string]struct{})
+	tagSet := make(map[string]struct{})
+	eventSet := make(map[string]struct{})
+	categorySet := make(map[string]struct{})
+	subjectSet := make(map[string]struct{})
+
+	var markerOptUpserts []mongo.WriteModel
+	var tagOptUpserts []mongo.WriteModel
+	var eventOptUpserts []mongo.WriteModel
+	var categoryOptUpserts []mongo.WriteModel
+	var subjectUpserts []mongo.WriteModel
+
+	var markerRangeDocs []interface{}
+	var tagRangeDocs []interface{}
+	var eventRangeDocs []interface{}
+
+	var markerNames []string
+	var tagNames []string
+	var eventNames []string
+	var subjectUIDs []string
+
+	now := time.Now().Unix()
+
+	for _, marker := range markers {
 		if marker.Name != "" {
-			markerNames = append(markerNames, marker.Name)
+			key := marker.Name + "|" + marker.OrganisationId
+			if _, exists := nameSet[key]; !exists {
+				nameSet[key] = struct{}{}
+				var categoryNamesList []string
+				for _, cat := range marker.Categories {
+					if cat.Name != "" {
+						categoryNamesList = append(categoryNamesList, cat.Name)
+					}
+				}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": marker.Name, "organisationId": marker.OrganisationId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          marker.Name,
+						"text":           marker.Name,
+						"organisationId": marker.OrganisationId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{
+						"updatedAt": now,
+					},
+					"$addToSet": bson.M{
+						"categories": bson.M{"$each": categoryNamesList},
+					},
+				})
+				up.SetUpsert(true)
+				markerOptUpserts = append(markerOptUpserts, up)
+				markerNames = append(markerNames, marker.Name)
+			}
+			markerRangeDocs = append(markerRangeDocs, bson.M{
+				"markerId":       marker.Id,
+				"value":          marker.Name,
+				"text":           marker.Name,
+				"organisationId": marker.OrganisationId,
+				"start":          marker.StartTimestamp,
+				"end":            marker.EndTimestamp,
+				"deviceId":       marker.DeviceId,
+				"groupId":        marker.GroupId,
+				"createdAt":      now,
+			})
 		}
 
-		var tagNames []string
 		for _, tag := range marker.Tags {
-			if tag.Name != "" {
+			if tag.Name == "" {
+				continue
+			}
+			key := tag.Name + "|" + marker.OrganisationId
+			if _, exists := tagSet[key]; !exists {
+				tagSet[key] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": tag.Name, "organisationId": marker.OrganisationId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          tag.Name,
+						"text":           tag.Name,
+						"organisationId": marker.OrganisationId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{
+						"updatedAt": now,
+					},
+				})
+				up.SetUpsert(true)
+				tagOptUpserts = append(tagOptUpserts, up)
 				tagNames = append(tagNames, tag.Name)
 			}
+			tagRangeDocs = append(tagRangeDocs, bson.M{
+				"markerId":       marker.Id,
+				"value":          tag.Name,
+				"text":           tag.Name,
+				"organisationId": marker.OrganisationId,
+				"start":          marker.StartTimestamp,
+				"end":            marker.EndTimestamp,
+				"deviceId":       marker.DeviceId,
+				"groupId":        marker.GroupId,
+				"createdAt":      now,
+			})
 		}
 
-		var eventNames []string
 		for _, event := range marker.Events {
-			if event.Name != "" {
+			if event.Name == "" {
+				continue
+			}
+			key := event.Name + "|" + marker.OrganisationId
+			if _, exists := eventSet[key]; !exists {
+				eventSet[key] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": event.Name, "organisationId": marker.OrganisationId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          event.Name,
+						"text":           event.Name,
+						"organisationId": marker.OrganisationId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{
+						"updatedAt": now,
+					},
+				})
+				up.SetUpsert(true)
+				eventOptUpserts = append(eventOptUpserts, up)
 				eventNames = append(eventNames, event.Name)
 			}
+			eventRangeDocs = append(eventRangeDocs, bson.M{
+				"markerId":       marker.Id,
+				"value":          event.Name,
+				"text":           event.Name,
+				"organisationId": marker.OrganisationId,
+				"start":          event.StartTimestamp,
+				"end":            event.EndTimestamp,
+				"deviceId":       marker.DeviceId,
+				"groupId":        marker.GroupId,
+				"createdAt":      now,
+				"updatedAt":      now,
+			})
 		}
 
-		// Build update document using $addToSet with $each to ensure uniqueness
-		updateDoc := bson.M{}
-		if len(markerNames) > 0 {
-			updateDoc["markerNames"] = bson.M{"$each": markerNames}
+		for _, category := range marker.Categories {
+			if category.Name == "" {
+				continue
+			}
+			key := category.Name + "|" + marker.OrganisationId
+			if _, exists := categorySet[key]; !exists {
+				categorySet[key] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"value": category.Name, "organisationId": marker.OrganisationId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"value":          category.Name,
+						"text":           category.Name,
+						"organisationId": marker.OrganisationId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{
+						"updatedAt": now,
+					},
+				})
+				up.SetUpsert(true)
+				categoryOptUpserts = append(categoryOptUpserts, up)
+			}
 		}
-		if len(tagNames) > 0 {
-			updateDoc["tagNames"] = bson.M{"$each": tagNames}
+
+		if marker.Type == MarkerTypeFace && marker.Face.SubjectUID != "" {
+			key := marker.Face.SubjectUID + "|" + marker.OrganisationId
+			if _, exists := subjectSet[key]; !exists {
+				subjectSet[key] = struct{}{}
+				up := mongo.NewUpdateOneModel()
+				up.SetFilter(bson.M{"subjectUID": marker.Face.SubjectUID, "organisationId": marker.OrganisationId})
+				up.SetUpdate(bson.M{
+					"$setOnInsert": bson.M{
+						"subjectUID":     marker.Face.SubjectUID,
+						"organisationId": marker.OrganisationId,
+						"createdAt":      now,
+					},
+					"$set": bson.M{
+						"subjectSrc": marker.Face.SubjectSrc,
+						"updatedAt":  now,
+					},
+					"$addToSet": bson.M{
+						"faceIds": marker.Face.FaceID,
+					},
+				})
+				up.SetUpsert(true)
+				subjectUpserts = append(subjectUpserts, up)
+				subjectUIDs = append(subjectUIDs, marker.Face.SubjectUID)
+			}
 		}
-		if len(eventNames) > 0 {
-			updateDoc["eventNames"] = bson.M{"$each": eventNames}
+	}
+
+	if len(markerOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_OPTIONS_COLLECTION).BulkWrite(ctx, markerOptUpserts); err != nil {
+			return nil, fmt.Errorf("failed to upsert marker options: %w", err)
+		}
+	}
+	if len(markerRangeDocs) > 0 {
+		if _, err := db.Collection(MARKER_OPTION_RANGES_COLLECTION).InsertMany(ctx, markerRangeDocs); err != nil {
+			return nil, fmt.Errorf("failed to insert marker ranges: %w", err)
 		}
+	}
+	if len(tagOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_TAG_OPTIONS_COLLECTION).BulkWrite(ctx, tagOptUpserts); err != nil {
+			return nil, fmt.Errorf("failed to upsert tag options: %w", err)
+		}
+	}
+	if len(tagRangeDocs) > 0 {
+		if _, err := db.Collection(MARKER_TAG_OPTION_RANGES_COLLECTION).InsertMany(ctx, tagRangeDocs); err != nil {
+			return nil, fmt.Errorf("failed to insert tag ranges: %w", err)
+		}
+	}
+	if len(eventOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_EVENT_OPTIONS_COLLECTION).BulkWrite(ctx, eventOptUpserts); err != nil {
+			return nil, fmt.Errorf("failed to upsert event options: %w", err)
+		}
+	}
+	if len(eventRangeDocs) > 0 {
+		if _, err := db.Collection(MARKER_EVENT_OPTION_RANGES_COLLECTION).InsertMany(ctx, eventRangeDocs); err != nil {
+			return nil, fmt.Errorf("failed to insert event ranges: %w", err)
+		}
+	}
+	if len(categoryOptUpserts) > 0 {
+		if _, err := db.Collection(MARKER_CATEGORY_OPTIONS_COLLECTION).BulkWrite(ctx, categoryOptUpserts); err != nil {
+			return nil, fmt.Errorf("failed to upsert category options: %w", err)
+		}
+	}
+	if len(subjectUpserts) > 0 {
+		if _, err := db.Collection(MARKER_SUBJECTS_COLLECTION).BulkWrite(ctx, subjectUpserts); err != nil {
+			return nil, fmt.Errorf("failed to upsert marker subjects: %w", err)
+		}
+	}
 
-		if len(updateDoc) > 0 {
-			mediaCol := db.Collection(MEDIA_COLLECTION)
-			filter := bson.M{
-				"_id":            mediaObjectId,
-				"startTimestamp": bson.M{"$lte": marker.StartTimestamp},
-				"endTimestamp":   bson.M{"$gte": marker.StartTimestamp},
+	// Apply the aggregated names from the whole batch to every referenced
+	// media document. All markers in a batch are expected to belong to the
+	// same media (the high-volume case is a single video's worth of
+	// markers), so unlike the single-marker path we don't filter by
+	// start/end overlap here.
+	updateDoc := bson.M{}
+	if len(markerNames) > 0 {
+		updateDoc["markerNames"] = bson.M{"$each": markerNames}
+	}
+	if len(tagNames) > 0 {
+		updateDoc["tagNames"] = bson.M{"$each": tagNames}
+	}
+	if len(eventNames) > 0 {
+		updateDoc["eventNames"] = bson.M{"$each": eventNames}
+	}
+	if len(subjectUIDs) > 0 {
+		updateDoc["subjectUIDs"] = bson.M{"$each": subjectUIDs}
+	}
+
+	var denormalizedMediaIds []primitive.ObjectID
+	if len(updateDoc) > 0 {
+		mediaCol := db.Collection(MEDIA_COLLECTION)
+		for _, mediaId := range mediaIds {
+			if mediaId == "" {
+				continue
+			}
+			mediaObjectId, err := primitive.ObjectIDFromHex(mediaId)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mediaId format: %w", err)
 			}
 			update := bson.M{"$addToSet": updateDoc}
-			_, err := mediaCol.UpdateOne(ctx, filter, update)
+			res, err := mediaCol.UpdateOne(ctx, bson.M{"_id": mediaObjectId}, update)
 			if err != nil {
-				return marker, fmt.Errorf("failed to update media with marker data: %w", err)
+				return nil, fmt.Errorf("failed to update media with marker data: %w", err)
+			}
+			if res.MatchedCount > 0 {
+				denormalizedMediaIds = append(denormalizedMediaIds, mediaObjectId)
 			}
 		}
 	}
 
-	return marker, nil
+	// Record exactly which media documents every marker in the batch was
+	// denormalized onto (all markers in a batch share the same mediaIds), so
+	// Delete/Update can find and clean up the same documents later instead of
+	// guessing by deviceId/groupId/timestamp overlap.
+	if len(denormalizedMediaIds) > 0 {
+		markerIds := make([]primitive.ObjectID, len(markers))
+		for i, marker := range markers {
+			markerIds[i] = marker.Id
+		}
+		if _, err := c.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": markerIds}}, bson.M{
+			"$set": bson.M{"mediaIds": denormalizedMediaIds},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to record markers' denormalized media: %w", err)
+		}
+	}
+
+	return markers, nil
 }
@@ -0,0 +1,205 @@
+package markers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/uug-ai/models/pkg/models"
+	"github.com/uug-ai/trace/pkg/opentelemetry"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MarkerEvent change types.
+const (
+	MarkerEventCreated = "created"
+	MarkerEventUpdated = "updated"
+	MarkerEventDeleted = "deleted"
+)
+
+// MarkerEvent is the typed translation of a raw change stream document.
+type MarkerEvent struct {
+	Type string
+	// Marker is the document after the change. On delete, Marker is the
+	// document's pre-image (Watch only opens streams on collections with
+	// change stream pre- and post-images enabled), with Marker.Id taken from
+	// the change's documentKey; a delete without a pre-image does not match
+	// the organisation filter and is not emitted.
+	Marker models.Marker
+	// Collection is the Mongo collection the change originated from,
+	// distinguishing the range-collection streams IncludeOptionRanges adds
+	// from the markers collection itself.
+	Collection string
+	// ResumeToken lets a consumer restart Watch without missing events that
+	// happened while it was disconnected. It is set on every event and is
+	// specific to the stream of Collection, so Watch accepts it back in
+	// WatchFilter.ResumeToken only when IncludeOptionRanges is false (a
+	// single collection is being watched).
+	ResumeToken bson.Raw
+}
+
+// WatchFilter scopes a Watch call to one organisation and, optionally, an
+// earlier resume point.
+type WatchFilter struct {
+	OrganisationId string
+	// ResumeToken, if set, resumes the change stream after this point
+	// instead of starting from now.
+	ResumeToken bson.Raw
+	// IncludeOptionRanges also watches marker_option_ranges,
+	// marker_tag_option_ranges and marker_event_option_ranges.
+	IncludeOptionRanges bool
+}
+
+// Watch opens a MongoDB change stream on the markers collection (and,
+// if requested, the option-range collections) and emits a typed MarkerEvent
+// per change. Consumers use this instead of polling the denormalized option
+// collections to react to markers in realtime, e.g. refreshing a media
+// page's UI or reacting when a marker with a specific tag/event appears.
+//
+// The returned channel is closed once every underlying change stream ends,
+// which happens when ctxTracer is cancelled.
+func Watch(ctxTracer context.Context, tracer *opentelemetry.Tracer, client *mongo.Client, filter WatchFilter) (<-chan MarkerEvent, error) {
+
+	ctxWatch, span := tracer.CreateSpan(ctxTracer, map[string]string{})
+	defer span.End()
+
+	if len(filter.ResumeToken) > 0 && filter.IncludeOptionRanges {
+		return nil, errors.New("resume tokens are collection-specific and cannot be combined with IncludeOptionRanges")
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"$or": bson.A{
+				bson.M{
+					"operationType":              bson.M{"$in": bson.A{"insert", "update", "replace"}},
+					"fullDocument.organisationId": filter.OrganisationId,
+				},
+				bson.M{
+					"operationType": "delete",
+					"fullDocumentBeforeChange.organisationId": filter.OrganisationId,
+				},
+			},
+		}}},
+	}
+
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if len(filter.ResumeToken) > 0 {
+		opts.SetResumeAfter(filter.ResumeToken)
+	}
+
+	collections := []string{MARKERS_COLLECTION}
+	if filter.IncludeOptionRanges {
+		collections = append(collections,
+			MARKER_OPTION_RANGES_COLLECTION,
+			MARKER_TAG_OPTION_RANGES_COLLECTION,
+			MARKER_EVENT_OPTION_RANGES_COLLECTION,
+		)
+	}
+
+	db := client.Database(DatabaseName)
+
+	for _, collection := range collections {
+		enabled, err := hasPreAndPostImages(ctxWatch, db, collection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check change stream pre/post-images on %s: %w", collection, err)
+		}
+		if !enabled {
+			return nil, fmt.Errorf("changeStreamPreAndPostImages is not enabled on %s: delete events would be dropped without fullDocumentBeforeChange", collection)
+		}
+	}
+
+	events := make(chan MarkerEvent)
+
+	var wg sync.WaitGroup
+	for _, collection := range collections {
+		stream, err := db.Collection(collection).Watch(ctxWatch, pipeline, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open change stream on %s: %w", collection, err)
+		}
+		wg.Add(1)
+		go watchMarkerChanges(ctxTracer, collection, stream, events, &wg)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// hasPreAndPostImages reports whether collection has
+// changeStreamPreAndPostImages enabled, which Watch requires so that delete
+// events carry a fullDocumentBeforeChange instead of being unrecoverable.
+func hasPreAndPostImages(ctx context.Context, db *mongo.Database, collection string) (bool, error) {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.M{"name": collection})
+	if err != nil {
+		return false, err
+	}
+	if len(specs) == 0 {
+		return false, fmt.Errorf("collection %s does not exist", collection)
+	}
+
+	var opts struct {
+		ChangeStreamPreAndPostImages struct {
+			Enabled bool `bson:"enabled"`
+		} `bson:"changeStreamPreAndPostImages"`
+	}
+	if err := bson.Unmarshal(specs[0].Options, &opts); err != nil {
+		return false, fmt.Errorf("failed to decode collection options: %w", err)
+	}
+
+	return opts.ChangeStreamPreAndPostImages.Enabled, nil
+}
+
+func watchMarkerChanges(ctx context.Context, collection string, stream *mongo.ChangeStream, events chan<- MarkerEvent, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer stream.Close(ctx)
+
+	for stream.Next(ctx) {
+		var change struct {
+			OperationType            string        `bson:"operationType"`
+			FullDocument             models.Marker `bson:"fullDocument"`
+			FullDocumentBeforeChange models.Marker `bson:"fullDocumentBeforeChange"`
+			DocumentKey              struct {
+				Id primitive.ObjectID `bson:"_id"`
+			} `bson:"documentKey"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			continue
+		}
+
+		var eventType string
+		marker := change.FullDocument
+		switch change.OperationType {
+		case "insert":
+			eventType = MarkerEventCreated
+		case "update", "replace":
+			eventType = MarkerEventUpdated
+		case "delete":
+			eventType = MarkerEventDeleted
+			marker = change.FullDocumentBeforeChange
+			marker.Id = change.DocumentKey.Id
+		default:
+			continue
+		}
+
+		select {
+		case events <- MarkerEvent{
+			Type:        eventType,
+			Marker:      marker,
+			Collection:  collection,
+			ResumeToken: stream.ResumeToken(),
+		}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}